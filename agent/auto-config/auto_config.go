@@ -2,15 +2,15 @@ package autoconf
 
 import (
 	"context"
+	"crypto/x509/pkix"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"sync"
 	"time"
 
 	"github.com/hashicorp/consul/agent/cache"
 	"github.com/hashicorp/consul/agent/config"
 	"github.com/hashicorp/consul/agent/token"
-	"github.com/hashicorp/consul/lib"
 	"github.com/hashicorp/consul/logging"
 	"github.com/hashicorp/consul/proto/pbautoconf"
 	"github.com/hashicorp/go-hclog"
@@ -24,7 +24,6 @@ type AutoConfig struct {
 	acConfig           Config
 	logger             hclog.Logger
 	cache              Cache
-	waiter             *lib.RetryWaiter
 	config             *config.RuntimeConfig
 	autoConfigResponse *pbautoconf.AutoConfigResponse
 	autoConfigSource   config.Source
@@ -83,8 +82,11 @@ func New(config Config) (*AutoConfig, error) {
 		logger = logger.Named(logging.AutoConfig)
 	}
 
-	if config.Waiter == nil {
-		config.Waiter = lib.NewRetryWaiter(1, 0, 10*time.Minute, lib.NewJitterRandomStagger(25))
+	if config.Backoff == nil {
+		config.Backoff = NewBackoffPolicy(0, 0, 0, 0, 0)
+	}
+	if config.Backoff.Logger == nil {
+		config.Backoff.Logger = logger
 	}
 
 	return &AutoConfig{
@@ -174,30 +176,18 @@ func (ac *AutoConfig) InitialConfiguration(ctx context.Context) (*config.Runtime
 }
 
 // introToken is responsible for determining the correct intro token to use
-// when making the initial AutoConfig.InitialConfiguration RPC request.
-func (ac *AutoConfig) introToken() (string, error) {
-	conf := ac.config.AutoConfig
-	// without an intro token or intro token file we cannot do anything
-	if conf.IntroToken == "" && conf.IntroTokenFile == "" {
-		return "", fmt.Errorf("neither intro_token or intro_token_file settings are not configured")
-	}
-
-	token := conf.IntroToken
-	if token == "" {
-		// load the intro token from the file
-		content, err := ioutil.ReadFile(conf.IntroTokenFile)
-		if err != nil {
-			return "", fmt.Errorf("Failed to read intro token from file: %w", err)
-		}
-
-		token = string(content)
-
-		if token == "" {
-			return "", fmt.Errorf("intro_token_file did not contain any token")
-		}
+// when making the initial AutoConfig.InitialConfiguration RPC request. It is
+// consulted on every call to getInitialConfigurationOnce so that providers
+// backed by a rotating secret (Vault, cloud IMDS, OIDC) can hand back a fresh
+// token on each retry.
+func (ac *AutoConfig) introToken(ctx context.Context) (string, error) {
+	provider := ac.acConfig.IntroTokenProvider
+	if provider == nil {
+		conf := ac.config.AutoConfig
+		provider = &StaticIntroTokenProvider{Token: conf.IntroToken, TokenFile: conf.IntroTokenFile}
 	}
 
-	return token, nil
+	return provider.IntroToken(ctx)
 }
 
 func (ac *AutoConfig) recordInitialResponse(resp *pbautoconf.AutoConfigResponse) error {
@@ -231,7 +221,7 @@ func (ac *AutoConfig) recordResponse(resp *pbautoconf.AutoConfigResponse) error
 // successfully recorded the auto config settings (persisted to disk and stored internally
 // on the AutoConfig object)
 func (ac *AutoConfig) getInitialConfigurationOnce(ctx context.Context, csr string, key string) (*pbautoconf.AutoConfigResponse, error) {
-	token, err := ac.introToken()
+	token, err := ac.introToken(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +241,22 @@ func (ac *AutoConfig) getInitialConfigurationOnce(ctx context.Context, csr strin
 		return nil, err
 	}
 
+	if ac.acConfig.ServerDiscovery != nil {
+		discovered, err := ac.acConfig.ServerDiscovery.DiscoverServers(ctx)
+		if err != nil {
+			// discovery failures are not fatal since the statically
+			// configured hosts, if any, may still be reachable
+			ac.logger.Warn("server discovery failed", "error", err)
+		}
+		servers = mergeDiscoveredServers(servers, discovered)
+	}
+
 	for _, s := range servers {
+		if quarantined, remaining := ac.acConfig.Backoff.Quarantined(s); quarantined {
+			ac.logger.Info("skipping quarantined server", "server", s, "remaining", remaining)
+			continue
+		}
+
 		// try each IP to see if we can successfully make the request
 		for _, addr := range ac.resolveHost(s) {
 			if ctx.Err() != nil {
@@ -261,9 +266,11 @@ func (ac *AutoConfig) getInitialConfigurationOnce(ctx context.Context, csr strin
 			ac.logger.Debug("making AutoConfig.InitialConfiguration RPC", "addr", addr.String())
 			if err = ac.acConfig.DirectRPC.RPC(ac.config.Datacenter, ac.config.NodeName, &addr, "AutoConfig.InitialConfiguration", &request, &resp); err != nil {
 				ac.logger.Error("AutoConfig.InitialConfiguration RPC failed", "addr", addr.String(), "error", err)
+				ac.acConfig.Backoff.RecordFailure(s)
 				continue
 			}
 			ac.logger.Debug("AutoConfig.InitialConfiguration RPC was successful")
+			ac.acConfig.Backoff.RecordSuccess(s)
 
 			// update the Certificate with the private key we generated locally
 			if resp.Certificate != nil {
@@ -277,9 +284,26 @@ func (ac *AutoConfig) getInitialConfigurationOnce(ctx context.Context, csr strin
 	return nil, fmt.Errorf("No servers successfully responded to the auto-config request")
 }
 
+// generateCSR generates the CSR used to request the agent's initial
+// certificate, delegating the actual key generation and signing to the
+// configured KeyProvider. The returned key is either the PEM-encoded private
+// key for software providers or an opaque key handle URI for hardware-backed
+// providers, and is carried unmodified through to the AutoConfigResponse's
+// Certificate.PrivateKeyPEM field.
+func (ac *AutoConfig) generateCSR() (csr string, key string, err error) {
+	provider := ac.acConfig.KeyProvider
+	if provider == nil {
+		provider = &InMemoryKeyProvider{}
+	}
+
+	subject := pkix.Name{CommonName: fmt.Sprintf("%s.%s.consul", ac.config.NodeName, ac.config.Datacenter)}
+	return provider.GenerateCSR(subject)
+}
+
 // getInitialConfiguration implements a loop to retry calls to getInitialConfigurationOnce.
-// It uses the RetryWaiter on the AutoConfig object to control how often to attempt
-// the initial configuration process. It is also canceallable by cancelling the provided context.
+// It uses the BackoffPolicy on the AutoConfig object to control how long to wait between
+// attempts and which servers to skip due to repeated failures. It is also cancellable by
+// cancelling the provided context.
 func (ac *AutoConfig) getInitialConfiguration(ctx context.Context) (*pbautoconf.AutoConfigResponse, error) {
 	// generate a CSR
 	csr, key, err := ac.generateCSR()
@@ -287,20 +311,33 @@ func (ac *AutoConfig) getInitialConfiguration(ctx context.Context) (*pbautoconf.
 		return nil, err
 	}
 
-	// this resets the failures so that we will perform immediate request
-	wait := ac.acConfig.Waiter.Success()
+	// reset the backoff so that the first attempt is always immediate
+	ac.acConfig.Backoff.Reset()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
 	for {
 		select {
-		case <-wait:
+		case <-timer.C:
 			if resp, err := ac.getInitialConfigurationOnce(ctx, csr, key); err == nil && resp != nil {
 				return resp, nil
 			} else if err != nil {
+				var denied *IntroTokenDeniedError
+				if errors.As(err, &denied) {
+					ac.logger.Error("obtaining an intro token was denied, not retrying", "error", err)
+					return nil, err
+				}
 				ac.logger.Error(err.Error())
 			} else {
 				ac.logger.Error("No error returned when fetching configuration from the servers but no response was either")
 			}
 
-			wait = ac.acConfig.Waiter.Failed()
+			wait, err := ac.acConfig.Backoff.NextBackoff()
+			if err != nil {
+				return nil, err
+			}
+			ac.logger.Debug("retrying initial auto configuration", "wait", wait)
+			timer.Reset(wait)
 		case <-ctx.Done():
 			ac.logger.Info("interrupted during initial auto configuration", "err", ctx.Err())
 			return nil, ctx.Err()
@@ -320,11 +357,33 @@ func (ac *AutoConfig) update(resp *pbautoconf.AutoConfigResponse) error {
 		Config: translateConfig(resp.Config),
 	}
 
-	if err := ac.updateTLSFromResponse(resp); err != nil {
-		return err
+	return ac.applyIssuedCertificate(resp)
+}
+
+// applyIssuedCertificate pushes the certificate carried on resp into the
+// agent's TLS configuration, the way updateTLSFromResponse used to for the
+// historical, software-only key case. When the certificate was issued for a
+// key generated by a hardware-backed KeyProvider, resp.Certificate.PrivateKeyPEM
+// holds an opaque key handle URI rather than a PEM-encoded private key; in
+// that case the key is never reconstructed in memory, and instead a
+// crypto.Signer that resolves back through the KeyProvider is handed to the
+// TLS configurator.
+func (ac *AutoConfig) applyIssuedCertificate(resp *pbautoconf.AutoConfigResponse) error {
+	if resp.Certificate == nil {
+		return nil
 	}
 
-	return nil
+	keyRef := resp.Certificate.PrivateKeyPEM
+	if ac.acConfig.KeyProvider == nil || isPEMEncodedKey(keyRef) {
+		return ac.acConfig.TLSConfigurator.UpdateAutoTLSCert(resp.Certificate.CertPEM, keyRef)
+	}
+
+	signer, err := newKeyProviderSigner(ac.acConfig.KeyProvider, keyRef, resp.Certificate.CertPEM)
+	if err != nil {
+		return fmt.Errorf("failed to resolve hardware-backed key %q: %w", keyRef, err)
+	}
+
+	return ac.acConfig.TLSConfigurator.UpdateAutoTLSCertWithSigner(resp.Certificate.CertPEM, signer)
 }
 
 func (ac *AutoConfig) Start(ctx context.Context) error {
@@ -0,0 +1,112 @@
+package autoconf
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/config"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIntroTokenProvider returns errs[i] on the i'th call (clamped to the
+// last element once exhausted), letting a test script a sequence of
+// transient failures followed by success or a fatal denial.
+type fakeIntroTokenProvider struct {
+	errs  []error
+	calls int
+}
+
+func (p *fakeIntroTokenProvider) IntroToken(context.Context) (string, error) {
+	idx := p.calls
+	if idx >= len(p.errs) {
+		idx = len(p.errs) - 1
+	}
+	p.calls++
+
+	if err := p.errs[idx]; err != nil {
+		return "", err
+	}
+	return "test-token", nil
+}
+
+// fakeDirectRPC always succeeds, recording how many times it was called.
+type fakeDirectRPC struct {
+	calls int
+}
+
+func (f *fakeDirectRPC) RPC(string, string, net.Addr, string, interface{}, interface{}) error {
+	f.calls++
+	return nil
+}
+
+// fakeServerDiscovery hands back a single, already-resolvable server address
+// so that getInitialConfigurationOnce has a host to dial without depending
+// on real DNS or static server_addresses configuration.
+type fakeServerDiscovery struct{}
+
+func (fakeServerDiscovery) DiscoverServers(context.Context) ([]string, error) {
+	return []string{"127.0.0.1:8300"}, nil
+}
+
+func testAutoConfig(t *testing.T, introToken IntroTokenProvider, rpc DirectRPC) *AutoConfig {
+	t.Helper()
+	return &AutoConfig{
+		acConfig: Config{
+			DirectRPC:          rpc,
+			IntroTokenProvider: introToken,
+			ServerDiscovery:    fakeServerDiscovery{},
+			Backoff:            NewBackoffPolicy(time.Millisecond, 2*time.Millisecond, 0, 0, 0),
+		},
+		logger: hclog.NewNullLogger(),
+		config: &config.RuntimeConfig{
+			NodeName:   "test-node",
+			Datacenter: "dc1",
+		},
+	}
+}
+
+// testContext bounds a test with a deadline so that a real regression in the
+// retry loop (e.g. no reachable server, or an unbounded backoff) fails the
+// test quickly instead of hanging until go test's package timeout.
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestGetInitialConfiguration_RetriesOnUnavailableToken(t *testing.T) {
+	introToken := &fakeIntroTokenProvider{errs: []error{
+		ErrIntroTokenUnavailable,
+		ErrIntroTokenUnavailable,
+		nil,
+	}}
+	rpc := &fakeDirectRPC{}
+	ac := testAutoConfig(t, introToken, rpc)
+
+	resp, err := ac.getInitialConfiguration(testContext(t))
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, 3, introToken.calls)
+	require.Equal(t, 1, rpc.calls)
+}
+
+func TestGetInitialConfiguration_FatalOnTokenDenied(t *testing.T) {
+	introToken := &fakeIntroTokenProvider{errs: []error{
+		&IntroTokenDeniedError{Reason: "no soup for you"},
+	}}
+	rpc := &fakeDirectRPC{}
+	ac := testAutoConfig(t, introToken, rpc)
+
+	resp, err := ac.getInitialConfiguration(testContext(t))
+	require.Error(t, err)
+	require.Nil(t, resp)
+
+	var denied *IntroTokenDeniedError
+	require.ErrorAs(t, err, &denied)
+	require.Equal(t, 1, introToken.calls, "should not retry once the token is authoritatively denied")
+	require.Equal(t, 0, rpc.calls, "should never attempt the RPC without a token")
+}
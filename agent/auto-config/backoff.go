@@ -0,0 +1,178 @@
+package autoconf
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	defaultBackoffBase           = 1 * time.Second
+	defaultBackoffCap            = 10 * time.Minute
+	defaultBackoffMaxElapsedTime = 0 // no limit, matching the historical behavior
+	defaultBreakerThreshold      = 5
+	defaultBreakerCooldown       = 2 * time.Minute
+)
+
+// BackoffPolicy implements "full jitter" exponential backoff between
+// retries of the initial configuration RPC, along with a circuit breaker
+// that quarantines individual server hosts which have failed repeatedly so
+// that they are skipped for a cooldown period rather than being retried on
+// every outer loop iteration.
+//
+//	sleep = random(0, min(Cap, Base * 2^attempt))
+type BackoffPolicy struct {
+	// Base is the initial backoff duration used for the first failed
+	// attempt.
+	Base time.Duration
+
+	// Cap bounds how large the backoff can grow regardless of how many
+	// attempts have failed.
+	Cap time.Duration
+
+	// MaxElapsedTime bounds the total time spent retrying since the last
+	// success. Zero means retry indefinitely.
+	MaxElapsedTime time.Duration
+
+	// BreakerThreshold is the number of consecutive failures against a
+	// single host before it is quarantined for BreakerCooldown.
+	BreakerThreshold int
+
+	// BreakerCooldown is how long a host is quarantined once it has
+	// reached BreakerThreshold consecutive failures.
+	BreakerCooldown time.Duration
+
+	// Logger, when set, is used to tell operators which servers get
+	// quarantined and why. When nil, quarantine transitions are not logged.
+	Logger hclog.Logger
+
+	mu        sync.Mutex
+	attempt   int
+	startedAt time.Time
+	hosts     map[string]*hostBreakerState
+}
+
+// hostBreakerState tracks consecutive failures for a single host so it can
+// be quarantined once it crosses the breaker threshold.
+type hostBreakerState struct {
+	consecutiveFailures int
+	quarantinedUntil    time.Time
+}
+
+// NewBackoffPolicy returns a BackoffPolicy with the given settings, applying
+// sane defaults for any zero-valued fields.
+func NewBackoffPolicy(base, cap, maxElapsedTime time.Duration, breakerThreshold int, breakerCooldown time.Duration) *BackoffPolicy {
+	if base == 0 {
+		base = defaultBackoffBase
+	}
+	if cap == 0 {
+		cap = defaultBackoffCap
+	}
+	if breakerThreshold == 0 {
+		breakerThreshold = defaultBreakerThreshold
+	}
+	if breakerCooldown == 0 {
+		breakerCooldown = defaultBreakerCooldown
+	}
+
+	return &BackoffPolicy{
+		Base:             base,
+		Cap:              cap,
+		MaxElapsedTime:   maxElapsedTime,
+		BreakerThreshold: breakerThreshold,
+		BreakerCooldown:  breakerCooldown,
+		hosts:            make(map[string]*hostBreakerState),
+	}
+}
+
+// Reset clears the attempt counter and elapsed time tracking, and should be
+// called whenever a request succeeds so that the next failure starts
+// backing off from Base again.
+func (b *BackoffPolicy) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attempt = 0
+	b.startedAt = time.Time{}
+}
+
+// errMaxElapsedTimeExceeded is returned by NextBackoff once MaxElapsedTime
+// has been exceeded, indicating that getInitialConfiguration should stop
+// retrying.
+var errMaxElapsedTimeExceeded = fmt.Errorf("maximum elapsed time for initial configuration retries has been exceeded")
+
+// NextBackoff returns how long to wait before the next attempt, using full
+// jitter exponential backoff, and records that an attempt has failed.
+func (b *BackoffPolicy) NextBackoff() (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.startedAt.IsZero() {
+		b.startedAt = time.Now()
+	} else if b.MaxElapsedTime > 0 && time.Since(b.startedAt) > b.MaxElapsedTime {
+		return 0, errMaxElapsedTimeExceeded
+	}
+
+	capped := math.Min(float64(b.Cap), float64(b.Base)*math.Pow(2, float64(b.attempt)))
+	b.attempt++
+
+	return time.Duration(rand.Int63n(int64(capped) + 1)), nil
+}
+
+// RecordFailure increments the consecutive failure counter for host and
+// quarantines it once BreakerThreshold has been reached.
+func (b *BackoffPolicy) RecordFailure(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.hosts[host]
+	if !ok {
+		state = &hostBreakerState{}
+		b.hosts[host] = state
+	}
+
+	state.consecutiveFailures++
+	if state.consecutiveFailures >= b.BreakerThreshold && state.quarantinedUntil.IsZero() {
+		state.quarantinedUntil = time.Now().Add(b.BreakerCooldown)
+		if b.Logger != nil {
+			b.Logger.Warn("quarantining server after repeated failures",
+				"server", host,
+				"consecutive_failures", state.consecutiveFailures,
+				"cooldown", b.BreakerCooldown,
+			)
+		}
+	}
+}
+
+// RecordSuccess clears the failure counter and any quarantine for host.
+func (b *BackoffPolicy) RecordSuccess(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.hosts, host)
+}
+
+// Quarantined reports whether host is currently being skipped due to
+// repeated failures, along with how much longer it will remain quarantined.
+func (b *BackoffPolicy) Quarantined(host string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.hosts[host]
+	if !ok || state.quarantinedUntil.IsZero() {
+		return false, 0
+	}
+
+	remaining := time.Until(state.quarantinedUntil)
+	if remaining <= 0 {
+		// the cooldown has elapsed; give the host another chance and
+		// reset its failure count so it isn't immediately re-quarantined
+		// on a single subsequent failure.
+		delete(b.hosts, host)
+		return false, 0
+	}
+
+	return true, remaining
+}
@@ -0,0 +1,127 @@
+package autoconf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffPolicy_NextBackoff(t *testing.T) {
+	t.Run("grows with attempts but stays within full jitter bounds", func(t *testing.T) {
+		b := NewBackoffPolicy(10*time.Millisecond, 100*time.Millisecond, 0, 0, 0)
+
+		capForAttempt := 10 * time.Millisecond
+		for i := 0; i < 6; i++ {
+			wait, err := b.NextBackoff()
+			require.NoError(t, err)
+			require.GreaterOrEqual(t, wait, time.Duration(0))
+			require.LessOrEqual(t, wait, capForAttempt)
+
+			if capForAttempt < 100*time.Millisecond {
+				capForAttempt *= 2
+				if capForAttempt > 100*time.Millisecond {
+					capForAttempt = 100 * time.Millisecond
+				}
+			}
+		}
+	})
+
+	t.Run("Reset restarts the attempt counter", func(t *testing.T) {
+		b := NewBackoffPolicy(10*time.Millisecond, 100*time.Millisecond, 0, 0, 0)
+
+		for i := 0; i < 5; i++ {
+			_, err := b.NextBackoff()
+			require.NoError(t, err)
+		}
+
+		b.Reset()
+		wait, err := b.NextBackoff()
+		require.NoError(t, err)
+		require.LessOrEqual(t, wait, 10*time.Millisecond)
+	})
+
+	t.Run("returns an error once MaxElapsedTime is exceeded", func(t *testing.T) {
+		b := NewBackoffPolicy(time.Millisecond, time.Millisecond, 5*time.Millisecond, 0, 0)
+
+		_, err := b.NextBackoff()
+		require.NoError(t, err)
+
+		time.Sleep(10 * time.Millisecond)
+
+		_, err = b.NextBackoff()
+		require.ErrorIs(t, err, errMaxElapsedTimeExceeded)
+	})
+}
+
+func TestBackoffPolicy_CircuitBreaker(t *testing.T) {
+	t.Run("quarantines a host after BreakerThreshold consecutive failures", func(t *testing.T) {
+		b := NewBackoffPolicy(0, 0, 0, 2, 50*time.Millisecond)
+
+		quarantined, _ := b.Quarantined("server1")
+		require.False(t, quarantined)
+
+		b.RecordFailure("server1")
+		quarantined, _ = b.Quarantined("server1")
+		require.False(t, quarantined, "should not be quarantined before reaching the threshold")
+
+		b.RecordFailure("server1")
+		quarantined, remaining := b.Quarantined("server1")
+		require.True(t, quarantined)
+		require.Greater(t, remaining, time.Duration(0))
+	})
+
+	t.Run("RecordSuccess clears the quarantine", func(t *testing.T) {
+		b := NewBackoffPolicy(0, 0, 0, 1, time.Minute)
+
+		b.RecordFailure("server1")
+		quarantined, _ := b.Quarantined("server1")
+		require.True(t, quarantined)
+
+		b.RecordSuccess("server1")
+		quarantined, _ = b.Quarantined("server1")
+		require.False(t, quarantined)
+	})
+
+	t.Run("host is no longer quarantined once the cooldown elapses", func(t *testing.T) {
+		b := NewBackoffPolicy(0, 0, 0, 1, 10*time.Millisecond)
+
+		b.RecordFailure("server1")
+		quarantined, _ := b.Quarantined("server1")
+		require.True(t, quarantined)
+
+		time.Sleep(20 * time.Millisecond)
+
+		quarantined, _ = b.Quarantined("server1")
+		require.False(t, quarantined)
+	})
+
+	t.Run("hosts are tracked independently", func(t *testing.T) {
+		b := NewBackoffPolicy(0, 0, 0, 1, time.Minute)
+
+		b.RecordFailure("server1")
+		quarantined1, _ := b.Quarantined("server1")
+		quarantined2, _ := b.Quarantined("server2")
+		require.True(t, quarantined1)
+		require.False(t, quarantined2)
+	})
+
+	t.Run("logs once when a host crosses the threshold", func(t *testing.T) {
+		var buf bytes.Buffer
+		b := NewBackoffPolicy(0, 0, 0, 2, time.Minute)
+		b.Logger = hclog.New(&hclog.LoggerOptions{Output: &buf, Level: hclog.Debug})
+
+		b.RecordFailure("server1")
+		require.Empty(t, buf.String(), "should not log before the threshold is reached")
+
+		b.RecordFailure("server1")
+		require.Contains(t, buf.String(), "quarantining server")
+		require.Contains(t, buf.String(), "server1")
+
+		buf.Reset()
+		b.RecordFailure("server1")
+		require.Empty(t, buf.String(), "should not re-log on every subsequent failure while already quarantined")
+	})
+}
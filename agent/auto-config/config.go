@@ -0,0 +1,99 @@
+package autoconf
+
+import (
+	"context"
+	"crypto"
+	"net"
+	"time"
+
+	"github.com/hashicorp/consul/agent/cache"
+	"github.com/hashicorp/consul/agent/config"
+	"github.com/hashicorp/consul/agent/token"
+	"github.com/hashicorp/go-hclog"
+)
+
+// DirectRPC is the interface that needs to be satisfied for AutoConfig to be able to perform
+// RPC calls to a server directly, without going through the router or leader election.
+type DirectRPC interface {
+	RPC(dc string, node string, addr net.Addr, method string, args interface{}, reply interface{}) error
+}
+
+// Cache is the interface that needs to be satisfied for AutoConfig to be able to prepopulate
+// and watch entries in the agent's cache such as the leaf certificate used for autoTLS.
+type Cache interface {
+	Notify(ctx context.Context, t string, r cache.Request, correlationID string, ch chan<- cache.UpdateEvent) error
+	Prepopulate(t string, result cache.FetchResult, dc, peerName, token string, key string) error
+}
+
+// TLSConfigurator is the interface that needs to be satisfied for AutoConfig to be able to
+// push newly retrieved certificates and CA roots into the agent's TLS configuration.
+type TLSConfigurator interface {
+	UpdateAutoTLSCert(pub, priv string) error
+
+	// UpdateAutoTLSCertWithSigner is used in place of UpdateAutoTLSCert when
+	// the private key backing pub is held by a hardware provider (PKCS#11,
+	// TPM) rather than a PEM-encoded key, so that the key material never
+	// has to be reconstructed in process memory. signer proves possession
+	// of the key by calling back into the originating KeyProvider.
+	UpdateAutoTLSCertWithSigner(pub string, signer crypto.Signer) error
+
+	UpdateAutoTLSCA(pems []string) error
+}
+
+// Config is used to configure an AutoConfig instance and provides all the
+// external dependencies that are required to perform the initial configuration
+// and to keep it up to date.
+type Config struct {
+	// Loader will be used to load the configuration as well as any auto-config
+	// settings already on disk.
+	Loader config.Loader
+
+	// DirectRPC is used to make the AutoConfig.InitialConfiguration RPC.
+	DirectRPC DirectRPC
+
+	// Cache is used to prepopulate the agent's cache with the certificates
+	// retrieved as part of the initial configuration process.
+	Cache Cache
+
+	// TLSConfigurator is used to push updated certificates into the running
+	// agent's TLS configuration.
+	TLSConfigurator TLSConfigurator
+
+	// Tokens is the shared token store used to detect Agent token updates
+	// so that the certificate watches can be restarted with the new token.
+	Tokens *token.Store
+
+	// IntroTokenProvider supplies the JWT used to introduce this agent to
+	// the servers during the initial configuration RPC. When unset, a
+	// StaticIntroTokenProvider is used so that the intro_token and
+	// intro_token_file settings continue to work unchanged.
+	IntroTokenProvider IntroTokenProvider
+
+	// ServerDiscovery, when set, is consulted on every retry of
+	// getInitialConfigurationOnce to find server addresses in addition to
+	// the statically configured ones, so that newly provisioned servers
+	// become reachable without an agent restart.
+	ServerDiscovery ServerDiscovery
+
+	// KeyProvider produces the key material and CSR used for the initial
+	// configuration RPC. When unset, an InMemoryKeyProvider generating an
+	// RSA key is used, matching the historical behavior.
+	KeyProvider KeyProvider
+
+	// Logger is the logger used by the AutoConfig object. When not provided
+	// a null logger will be used instead.
+	Logger hclog.Logger
+
+	// Backoff configures the full-jitter exponential backoff and per-host
+	// circuit breaker used between retries of the initial configuration
+	// RPC. When not provided a default policy will be used instead.
+	Backoff *BackoffPolicy
+
+	// FallbackLeeway is the amount of time after certificate expiration before
+	// the fallback routine of retrieving new certificates is executed.
+	FallbackLeeway time.Duration
+
+	// FallbackRetry is the duration between retries of the fallback routine
+	// when the routine fails to renew the certificates.
+	FallbackRetry time.Duration
+}
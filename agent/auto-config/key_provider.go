@@ -0,0 +1,83 @@
+package autoconf
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// KeyProvider is responsible for producing the key material backing the
+// agent's CSR and for signing with it thereafter. Software providers return
+// the PEM-encoded private key as the KeyRef, while hardware-backed providers
+// (PKCS#11, TPM) return an opaque key handle URI so that the private key
+// itself never needs to leave the device. The TLS configurator resolves a
+// KeyRef back through the same provider via Sign whenever it needs to prove
+// possession of the key, for example during a TLS handshake.
+type KeyProvider interface {
+	// GenerateCSR creates a new key and a CSR for the given subject,
+	// returning the PEM-encoded CSR and a reference to the key.
+	GenerateCSR(subject pkix.Name) (csrPEM string, keyRef string, err error)
+
+	// Sign produces a signature over digest using the key referenced by
+	// keyRef and previously returned from GenerateCSR.
+	Sign(keyRef string, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// KeyAlgorithm identifies the kind of key an InMemoryKeyProvider should
+// generate.
+type KeyAlgorithm string
+
+const (
+	KeyAlgorithmRSA       KeyAlgorithm = "rsa"
+	KeyAlgorithmECDSAP256 KeyAlgorithm = "ecdsa-p256"
+	KeyAlgorithmECDSAP384 KeyAlgorithm = "ecdsa-p384"
+	KeyAlgorithmEd25519   KeyAlgorithm = "ed25519"
+)
+
+const defaultInMemoryKeyBits = 2048
+
+// isPEMEncodedKey reports whether keyRef is a PEM-encoded private key, as
+// returned by the in-memory software KeyProvider, rather than an opaque key
+// handle URI returned by a hardware-backed one.
+func isPEMEncodedKey(keyRef string) bool {
+	return strings.HasPrefix(keyRef, "-----BEGIN")
+}
+
+// keyProviderSigner adapts a KeyProvider and the key handle URI it returned
+// from GenerateCSR into a crypto.Signer, so that a hardware-backed key can be
+// used to prove possession of a certificate without ever reconstructing the
+// private key in process memory.
+type keyProviderSigner struct {
+	provider KeyProvider
+	keyRef   string
+	public   crypto.PublicKey
+}
+
+// newKeyProviderSigner builds a keyProviderSigner for the key referenced by
+// keyRef, taking the public key from the issued certificate so that it
+// cannot drift from what the server actually signed.
+func newKeyProviderSigner(provider KeyProvider, keyRef, certPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return &keyProviderSigner{provider: provider, keyRef: keyRef, public: cert.PublicKey}, nil
+}
+
+func (s *keyProviderSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *keyProviderSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.provider.Sign(s.keyRef, digest, opts)
+}
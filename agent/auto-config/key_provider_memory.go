@@ -0,0 +1,124 @@
+package autoconf
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"sync"
+)
+
+// InMemoryKeyProvider is the default KeyProvider and reproduces the
+// historical behavior of generating the key material in-process. Keys are
+// kept only in memory, keyed by the PEM of the generated private key, which
+// doubles as the KeyRef since there is nothing to protect it from a
+// hardware provider's perspective.
+type InMemoryKeyProvider struct {
+	// Algorithm selects the key type to generate. Defaults to RSA when
+	// empty for backwards compatibility.
+	Algorithm KeyAlgorithm
+
+	mu   sync.Mutex
+	keys map[string]crypto.Signer
+}
+
+func (p *InMemoryKeyProvider) GenerateCSR(subject pkix.Name) (string, string, error) {
+	signer, keyPEM, err := p.generateKey()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	template := &x509.CertificateRequest{
+		Subject:            subject,
+		SignatureAlgorithm: signatureAlgorithmFor(signer),
+	}
+
+	bs, err := x509.CreateCertificateRequest(rand.Reader, template, signer)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create CSR: %w", err)
+	}
+
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: bs}))
+
+	p.mu.Lock()
+	if p.keys == nil {
+		p.keys = make(map[string]crypto.Signer)
+	}
+	p.keys[keyPEM] = signer
+	p.mu.Unlock()
+
+	return csrPEM, keyPEM, nil
+}
+
+func (p *InMemoryKeyProvider) Sign(keyRef string, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	p.mu.Lock()
+	signer, ok := p.keys[keyRef]
+	p.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key reference")
+	}
+
+	return signer.Sign(rand.Reader, digest, opts)
+}
+
+func (p *InMemoryKeyProvider) generateKey() (crypto.Signer, string, error) {
+	switch p.Algorithm {
+	case KeyAlgorithmECDSAP256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, "", err
+		}
+		return key, mustMarshalECKeyPEM(key), nil
+	case KeyAlgorithmECDSAP384:
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		if err != nil {
+			return nil, "", err
+		}
+		return key, mustMarshalECKeyPEM(key), nil
+	case KeyAlgorithmEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, "", err
+		}
+		return key, mustMarshalPKCS8KeyPEM(key), nil
+	case KeyAlgorithmRSA, "":
+		key, err := rsa.GenerateKey(rand.Reader, defaultInMemoryKeyBits)
+		if err != nil {
+			return nil, "", err
+		}
+		return key, mustMarshalPKCS8KeyPEM(key), nil
+	default:
+		return nil, "", fmt.Errorf("unsupported key algorithm: %s", p.Algorithm)
+	}
+}
+
+func signatureAlgorithmFor(signer crypto.Signer) x509.SignatureAlgorithm {
+	switch signer.(type) {
+	case ed25519.PrivateKey:
+		return x509.PureEd25519
+	default:
+		return x509.UnknownSignatureAlgorithm
+	}
+}
+
+func mustMarshalECKeyPEM(key *ecdsa.PrivateKey) string {
+	bs, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: bs}))
+}
+
+func mustMarshalPKCS8KeyPEM(key crypto.PrivateKey) string {
+	bs, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: bs}))
+}
@@ -0,0 +1,262 @@
+//go:build pkcs11
+
+package autoconf
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/miekg/pkcs11"
+)
+
+// pkcs11KeyIDSize is the length, in bytes, of the random CKA_ID assigned to
+// each generated key pair so that it can be found again by C_FindObjects in
+// a later session, since PKCS#11 object handles are not guaranteed to stay
+// valid, let alone refer to the same object, across sessions.
+const pkcs11KeyIDSize = 16
+
+// PKCS11KeyProvider generates and signs with keys held on a PKCS#11 token
+// such as a SoftHSM or YubiHSM, so that the agent's private key never
+// exists outside the module. GenerateCSR returns a "pkcs11:" key handle URI
+// as the KeyRef; the private key material itself is never returned.
+type PKCS11KeyProvider struct {
+	// ModulePath is the path to the vendor's PKCS#11 shared library.
+	ModulePath string
+	// SlotLabel identifies which token slot to use.
+	SlotLabel string
+	// PIN authenticates to the token.
+	PIN string
+
+	ctx *pkcs11.Ctx
+}
+
+func (p *PKCS11KeyProvider) session() (*pkcs11.Ctx, pkcs11.SessionHandle, error) {
+	if p.ctx == nil {
+		p.ctx = pkcs11.New(p.ModulePath)
+		if p.ctx == nil {
+			return nil, 0, fmt.Errorf("failed to load PKCS#11 module %q", p.ModulePath)
+		}
+		if err := p.ctx.Initialize(); err != nil {
+			return nil, 0, fmt.Errorf("failed to initialize PKCS#11 module: %w", err)
+		}
+	}
+
+	slot, err := findSlotByLabel(p.ctx, p.SlotLabel)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	session, err := p.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open PKCS#11 session: %w", err)
+	}
+
+	if err := p.ctx.Login(session, pkcs11.CKU_USER, p.PIN); err != nil {
+		return nil, 0, fmt.Errorf("failed to log in to PKCS#11 token: %w", err)
+	}
+
+	return p.ctx, session, nil
+}
+
+func (p *PKCS11KeyProvider) GenerateCSR(subject pkix.Name) (string, string, error) {
+	ctx, session, err := p.session()
+	if err != nil {
+		return "", "", err
+	}
+	defer ctx.CloseSession(session)
+
+	keyRef, signer, err := generatePKCS11KeyPair(ctx, session)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key pair on PKCS#11 token: %w", err)
+	}
+
+	template := &x509.CertificateRequest{Subject: subject}
+	bs, err := x509.CreateCertificateRequest(rand.Reader, template, signer)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create CSR: %w", err)
+	}
+
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: bs}))
+	return csrPEM, keyRef, nil
+}
+
+func (p *PKCS11KeyProvider) Sign(keyRef string, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	ctx, session, err := p.session()
+	if err != nil {
+		return nil, err
+	}
+	defer ctx.CloseSession(session)
+
+	return signWithPKCS11Key(ctx, session, keyRef, digest, opts)
+}
+
+func findSlotByLabel(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list PKCS#11 slots: %w", err)
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if info.Label == label {
+			return slot, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no PKCS#11 slot found with label %q", label)
+}
+
+// generatePKCS11KeyPair creates an ECDSA-P256 key pair on the token, tagged
+// with a random CKA_ID so the pair can be re-identified via C_FindObjects in
+// any later session, and returns a "pkcs11:" key handle URI encoding that ID
+// along with a crypto.Signer that signs through the token without ever
+// exporting the private key.
+func generatePKCS11KeyPair(ctx *pkcs11.Ctx, session pkcs11.SessionHandle) (string, crypto.Signer, error) {
+	id := make([]byte, pkcs11KeyIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("failed to generate PKCS#11 key id: %w", err)
+	}
+
+	pub, priv, err := ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}), // P-256 OID
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		},
+	)
+	if err != nil {
+		return "", nil, err
+	}
+
+	keyRef := fmt.Sprintf("pkcs11:id=%s", hex.EncodeToString(id))
+	return keyRef, &pkcs11Signer{ctx: ctx, session: session, pub: pub, keyRef: keyRef}, nil
+}
+
+// findObjectByIDAndClass resolves the single PKCS#11 object tagged with id
+// and class in the current session, since object handles from a previous
+// session are not safe to reuse.
+func findObjectByIDAndClass(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, id []byte, class uint) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+	}
+
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return 0, fmt.Errorf("failed to initialize PKCS#11 object search: %w", err)
+	}
+	defer ctx.FindObjectsFinal(session)
+
+	handles, _, err := ctx.FindObjects(session, 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search for PKCS#11 object: %w", err)
+	}
+	if len(handles) == 0 {
+		return 0, fmt.Errorf("no PKCS#11 object found for id %x and class %d", id, class)
+	}
+
+	return handles[0], nil
+}
+
+// parsePKCS11KeyRef extracts the CKA_ID encoded in a "pkcs11:id=<hex>" key
+// reference produced by generatePKCS11KeyPair.
+func parsePKCS11KeyRef(keyRef string) ([]byte, error) {
+	const prefix = "pkcs11:id="
+	if !strings.HasPrefix(keyRef, prefix) {
+		return nil, fmt.Errorf("invalid PKCS#11 key reference %q", keyRef)
+	}
+
+	id, err := hex.DecodeString(strings.TrimPrefix(keyRef, prefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PKCS#11 key reference %q: %w", keyRef, err)
+	}
+
+	return id, nil
+}
+
+// signWithPKCS11Key signs digest using the private key object tagged with
+// the CKA_ID encoded in keyRef, re-resolving the object handle in the
+// current session via C_FindObjects rather than trusting a handle captured
+// in a different session.
+func signWithPKCS11Key(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, keyRef string, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	id, err := parsePKCS11KeyRef(keyRef)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := findObjectByIDAndClass(ctx, session, id, pkcs11.CKO_PRIVATE_KEY)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve PKCS#11 private key for reference %q: %w", keyRef, err)
+	}
+
+	if err := ctx.SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, handle); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 signing: %w", err)
+	}
+
+	sig, err := ctx.Sign(session, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	// CKM_ECDSA yields r||s; crypto.Signer callers expect an ASN.1 ECDSA-Sig-Value.
+	half := len(sig) / 2
+	return asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(sig[:half]), new(big.Int).SetBytes(sig[half:])})
+}
+
+// pkcs11Signer adapts a PKCS#11 key pair to the crypto.Signer interface so
+// that it can be used directly with x509.CreateCertificateRequest while the
+// session used to generate it is still open.
+type pkcs11Signer struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	pub     pkcs11.ObjectHandle
+	keyRef  string
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	attrs, err := s.ctx.GetAttributeValue(s.session, s.pub, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil || len(attrs) == 0 {
+		return nil
+	}
+
+	x, y := elliptic.Unmarshal(elliptic.P256(), attrs[0].Value)
+	if x == nil {
+		// most modules return CKA_EC_POINT wrapped in a DER OCTET STRING
+		var raw []byte
+		if _, err := asn1.Unmarshal(attrs[0].Value, &raw); err == nil {
+			x, y = elliptic.Unmarshal(elliptic.P256(), raw)
+		}
+	}
+	if x == nil {
+		return nil
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+}
+
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return signWithPKCS11Key(s.ctx, s.session, s.keyRef, digest, opts)
+}
@@ -0,0 +1,76 @@
+//go:build linux && tpm
+
+package autoconf
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// TPMKeyProvider generates and signs with a key sealed inside a Linux TPM
+// 2.0, so that the private key never leaves the device even in memory.
+// GenerateCSR returns a "tpm:" key handle URI as the KeyRef.
+type TPMKeyProvider struct {
+	// DevicePath is the TPM character device, e.g. "/dev/tpmrm0".
+	DevicePath string
+	// PersistentHandle is where the generated key is made persistent so
+	// that it survives across GenerateCSR/Sign calls and process restarts.
+	PersistentHandle tpmutil.Handle
+}
+
+func (p *TPMKeyProvider) GenerateCSR(subject pkix.Name) (string, string, error) {
+	rwc, err := openTPM(p.DevicePath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open TPM device %q: %w", p.DevicePath, err)
+	}
+	defer rwc.Close()
+
+	key, err := client.NewKey(rwc, tpm2.HandleOwner, client.ECCP256Template())
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create TPM key: %w", err)
+	}
+	defer key.Close()
+
+	if err := tpm2.EvictControl(rwc, "", tpm2.HandleOwner, key.Handle(), p.PersistentHandle); err != nil {
+		return "", "", fmt.Errorf("failed to persist TPM key at handle 0x%x: %w", p.PersistentHandle, err)
+	}
+
+	template := &x509.CertificateRequest{Subject: subject}
+	bs, err := x509.CreateCertificateRequest(rand.Reader, template, key.GetSigner())
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create CSR: %w", err)
+	}
+
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: bs}))
+	keyRef := fmt.Sprintf("tpm:%s:handle=0x%x", p.DevicePath, p.PersistentHandle)
+	return csrPEM, keyRef, nil
+}
+
+func (p *TPMKeyProvider) Sign(keyRef string, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	rwc, err := openTPM(p.DevicePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open TPM device %q: %w", p.DevicePath, err)
+	}
+	defer rwc.Close()
+
+	key, err := client.LoadCachedKey(rwc, p.PersistentHandle, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TPM key referenced by %q: %w", keyRef, err)
+	}
+	defer key.Close()
+
+	return key.GetSigner().Sign(rand.Reader, digest, opts)
+}
+
+func openTPM(path string) (io.ReadWriteCloser, error) {
+	return tpm2.OpenTPM(path)
+}
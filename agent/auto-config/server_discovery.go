@@ -0,0 +1,185 @@
+package autoconf
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-discover"
+	discoverk8s "github.com/hashicorp/go-discover/provider/k8s"
+	"github.com/hashicorp/mdns"
+)
+
+// defaultDiscoveryTimeout bounds the DNS SRV lookup and the mDNS query so
+// that a slow or unreachable DNS server or mDNS network cannot stall an
+// entire retry cycle of getInitialConfigurationOnce. Cloud auto-join
+// discovery is not bounded by it.
+const defaultDiscoveryTimeout = 10 * time.Second
+
+// ServerDiscovery is used to find additional Consul server addresses beyond
+// those statically configured, so that the initial configuration RPC can
+// reach a cluster whose membership was not known at agent install time.
+// DiscoverServers is called once per retry of getInitialConfigurationOnce,
+// so implementations should perform a fresh lookup rather than caching
+// results for the lifetime of the process.
+type ServerDiscovery interface {
+	DiscoverServers(ctx context.Context) ([]string, error)
+}
+
+// DNSSRVServerDiscovery discovers servers by resolving a DNS SRV record,
+// e.g. "_consul._tcp.dc1.consul." per RFC 2782.
+type DNSSRVServerDiscovery struct {
+	// Service is the SRV service name, defaulting to "consul".
+	Service string
+	// Proto is the SRV protocol, defaulting to "tcp".
+	Proto string
+	// Domain is the domain to query, e.g. "dc1.consul".
+	Domain string
+
+	// Resolver defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+func (d *DNSSRVServerDiscovery) resolver() *net.Resolver {
+	if d.Resolver != nil {
+		return d.Resolver
+	}
+	return net.DefaultResolver
+}
+
+func (d *DNSSRVServerDiscovery) DiscoverServers(ctx context.Context) ([]string, error) {
+	service := d.Service
+	if service == "" {
+		service = "consul"
+	}
+	proto := d.Proto
+	if proto == "" {
+		proto = "tcp"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultDiscoveryTimeout)
+	defer cancel()
+
+	_, records, err := d.resolver().LookupSRV(ctx, service, proto, d.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve SRV records for %s.%s.%s: %w", service, proto, d.Domain, err)
+	}
+
+	servers := make([]string, 0, len(records))
+	for _, r := range records {
+		servers = append(servers, net.JoinHostPort(strings.TrimSuffix(r.Target, "."), fmt.Sprintf("%d", r.Port)))
+	}
+	return servers, nil
+}
+
+// MDNSServerDiscovery discovers servers advertised over mDNS on a link-local
+// network, useful for on-prem or edge deployments without central DNS.
+type MDNSServerDiscovery struct {
+	// Service is the mDNS service name to browse for, e.g. "_consul._tcp".
+	Service string
+	// Domain defaults to "local." when empty.
+	Domain string
+}
+
+func (d *MDNSServerDiscovery) DiscoverServers(ctx context.Context) ([]string, error) {
+	domain := d.Domain
+	if domain == "" {
+		domain = "local."
+	}
+
+	entries := make(chan *mdns.ServiceEntry, 16)
+	params := mdns.DefaultParams(d.Service)
+	params.Domain = domain
+	params.Entries = entries
+	params.Timeout = defaultDiscoveryTimeout
+
+	var servers []string
+	done := make(chan error, 1)
+	go func() {
+		done <- mdns.Query(params)
+	}()
+
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				return servers, nil
+			}
+			servers = append(servers, net.JoinHostPort(entry.AddrV4.String(), fmt.Sprintf("%d", entry.Port)))
+		case err := <-done:
+			close(entries)
+			if err != nil {
+				return servers, fmt.Errorf("mDNS discovery failed: %w", err)
+			}
+			return servers, nil
+		case <-ctx.Done():
+			return servers, ctx.Err()
+		}
+	}
+}
+
+// CloudAutoJoinServerDiscovery discovers servers via go-discover, supporting
+// the same "provider=... key=value ..." configuration strings used by
+// -retry-join for cloud providers (AWS, GCE, Azure) as well as Kubernetes.
+type CloudAutoJoinServerDiscovery struct {
+	// Config is a go-discover configuration string, e.g.
+	// "provider=aws tag_key=consul-server tag_value=true".
+	Config string
+
+	// Port is appended to each discovered address that doesn't already
+	// carry one, matching the server RPC port.
+	Port int
+
+	Logger *log.Logger
+}
+
+func (d *CloudAutoJoinServerDiscovery) DiscoverServers(ctx context.Context) ([]string, error) {
+	// discover.Providers is a shared package-level map; copy it before adding
+	// "k8s" so that running discovery does not race with (or permanently
+	// mutate) every other user of the go-discover package in this process.
+	providers := make(map[string]discover.Provider, len(discover.Providers)+1)
+	for name, provider := range discover.Providers {
+		providers[name] = provider
+	}
+	providers["k8s"] = &discoverk8s.Provider{}
+
+	disco, err := discover.New(discover.WithProviders(providers))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cloud auto-join discovery: %w", err)
+	}
+
+	addrs, err := disco.Addrs(d.Config, d.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("cloud auto-join discovery failed: %w", err)
+	}
+
+	servers := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil && d.Port != 0 {
+			addr = net.JoinHostPort(addr, fmt.Sprintf("%d", d.Port))
+		}
+		servers = append(servers, addr)
+	}
+	return servers, nil
+}
+
+// mergeDiscoveredServers combines the statically configured server hosts
+// with any discovered via ServerDiscovery, preserving order and suppressing
+// duplicates so that a server appearing in both lists is only tried once.
+func mergeDiscoveredServers(static, discovered []string) []string {
+	seen := make(map[string]struct{}, len(static)+len(discovered))
+	merged := make([]string, 0, len(static)+len(discovered))
+
+	for _, s := range append(append([]string{}, static...), discovered...) {
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		merged = append(merged, s)
+	}
+
+	return merged
+}
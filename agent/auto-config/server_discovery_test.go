@@ -0,0 +1,35 @@
+package autoconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeDiscoveredServers(t *testing.T) {
+	t.Run("dedupes while preserving order", func(t *testing.T) {
+		static := []string{"10.0.0.1:8300", "10.0.0.2:8300"}
+		discovered := []string{"10.0.0.2:8300", "10.0.0.3:8300"}
+
+		require.Equal(t,
+			[]string{"10.0.0.1:8300", "10.0.0.2:8300", "10.0.0.3:8300"},
+			mergeDiscoveredServers(static, discovered),
+		)
+	})
+
+	t.Run("handles empty inputs", func(t *testing.T) {
+		require.Empty(t, mergeDiscoveredServers(nil, nil))
+		require.Equal(t, []string{"10.0.0.1:8300"}, mergeDiscoveredServers([]string{"10.0.0.1:8300"}, nil))
+		require.Equal(t, []string{"10.0.0.1:8300"}, mergeDiscoveredServers(nil, []string{"10.0.0.1:8300"}))
+	})
+
+	t.Run("does not mutate its inputs", func(t *testing.T) {
+		static := []string{"10.0.0.1:8300"}
+		discovered := []string{"10.0.0.1:8300"}
+
+		mergeDiscoveredServers(static, discovered)
+
+		require.Equal(t, []string{"10.0.0.1:8300"}, static)
+		require.Equal(t, []string{"10.0.0.1:8300"}, discovered)
+	})
+}
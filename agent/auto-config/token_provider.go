@@ -0,0 +1,73 @@
+package autoconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// ErrIntroTokenUnavailable is returned by an IntroTokenProvider when the token
+// cannot be obtained yet but the caller should keep retrying, e.g. because the
+// backing secret store is still sealed or the metadata service has not
+// finished provisioning the instance.
+var ErrIntroTokenUnavailable = errors.New("intro token is not available yet")
+
+// IntroTokenDeniedError is returned by an IntroTokenProvider when the
+// provider was able to reach its backend but was authoritatively told that
+// no token will be issued. This is treated as a fatal error by
+// getInitialConfiguration since retrying is not expected to help.
+type IntroTokenDeniedError struct {
+	// Reason contains additional context from the backend about why the
+	// token was denied.
+	Reason string
+}
+
+func (e *IntroTokenDeniedError) Error() string {
+	if e.Reason == "" {
+		return "intro token request was denied"
+	}
+	return fmt.Sprintf("intro token request was denied: %s", e.Reason)
+}
+
+// IntroTokenProvider is used to obtain the JWT used to introduce this agent
+// to the servers when performing the AutoConfig.InitialConfiguration RPC.
+// Implementations are consulted on every retry of getInitialConfigurationOnce
+// so that they can hand back a freshly minted or rotated token rather than a
+// value cached for the lifetime of the process.
+type IntroTokenProvider interface {
+	// IntroToken returns the token to use for the next attempt. Returning
+	// ErrIntroTokenUnavailable indicates a transient condition that is
+	// worth retrying. Returning an *IntroTokenDeniedError indicates that
+	// the request should not be retried.
+	IntroToken(ctx context.Context) (string, error)
+}
+
+// StaticIntroTokenProvider is the default IntroTokenProvider and reproduces
+// the historical behavior of reading the token from the auto_config
+// intro_token or intro_token_file settings.
+type StaticIntroTokenProvider struct {
+	Token     string
+	TokenFile string
+}
+
+func (p *StaticIntroTokenProvider) IntroToken(_ context.Context) (string, error) {
+	if p.Token == "" && p.TokenFile == "" {
+		return "", fmt.Errorf("neither intro_token nor intro_token_file settings are configured")
+	}
+
+	if p.Token != "" {
+		return p.Token, nil
+	}
+
+	content, err := ioutil.ReadFile(p.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read intro token from file: %w", err)
+	}
+
+	if len(content) == 0 {
+		return "", fmt.Errorf("intro_token_file did not contain any token")
+	}
+
+	return string(content), nil
+}
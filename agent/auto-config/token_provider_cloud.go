@@ -0,0 +1,215 @@
+package autoconf
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultIMDSTimeout bounds how long we'll wait on a metadata service
+	// that is expected to answer on the local link in a few milliseconds.
+	defaultIMDSTimeout = 5 * time.Second
+
+	awsIMDSTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	awsIMDSTokenHeader    = "X-aws-ec2-metadata-token"
+)
+
+// AWSIMDSIntroTokenProvider obtains the intro token from the AWS Instance
+// Metadata Service (IMDSv2) in the form of a signed PKCS#7 identity document.
+// Servers that trust the account/region can verify the document's signature
+// instead of requiring a pre-shared secret.
+type AWSIMDSIntroTokenProvider struct {
+	// BaseURL defaults to the standard IMDS link-local address and is
+	// overridable for testing.
+	BaseURL string
+
+	// HTTPClient defaults to a client with a short timeout appropriate for
+	// a link-local metadata service.
+	HTTPClient *http.Client
+}
+
+func (p *AWSIMDSIntroTokenProvider) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return &http.Client{Timeout: defaultIMDSTimeout}
+}
+
+func (p *AWSIMDSIntroTokenProvider) baseURL() string {
+	if p.BaseURL != "" {
+		return p.BaseURL
+	}
+	return "http://169.254.169.254"
+}
+
+func (p *AWSIMDSIntroTokenProvider) IntroToken(ctx context.Context) (string, error) {
+	client := p.httpClient()
+
+	tokReq, err := http.NewRequestWithContext(ctx, http.MethodPut, p.baseURL()+"/latest/api/token", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build IMDSv2 token request: %w", err)
+	}
+	tokReq.Header.Set(awsIMDSTokenTTLHeader, "21600")
+
+	tokResp, err := client.Do(tokReq)
+	if err != nil {
+		return "", ErrIntroTokenUnavailable
+	}
+	defer tokResp.Body.Close()
+
+	if tokResp.StatusCode != http.StatusOK {
+		return "", ErrIntroTokenUnavailable
+	}
+
+	sessionToken, err := ioutil.ReadAll(tokResp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read IMDSv2 session token: %w", err)
+	}
+
+	docReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL()+"/latest/dynamic/instance-identity/pkcs7", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build instance identity document request: %w", err)
+	}
+	docReq.Header.Set(awsIMDSTokenHeader, string(sessionToken))
+
+	docResp, err := client.Do(docReq)
+	if err != nil {
+		return "", ErrIntroTokenUnavailable
+	}
+	defer docResp.Body.Close()
+
+	if docResp.StatusCode == http.StatusForbidden {
+		return "", &IntroTokenDeniedError{Reason: "IMDS denied access to the instance identity document"}
+	}
+	if docResp.StatusCode != http.StatusOK {
+		return "", ErrIntroTokenUnavailable
+	}
+
+	doc, err := ioutil.ReadAll(docResp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read instance identity document: %w", err)
+	}
+
+	return strings.TrimSpace(string(doc)), nil
+}
+
+// GCPIntroTokenProvider obtains the intro token as a signed JWT issued by
+// the instance's attached service account via the metadata server.
+type GCPIntroTokenProvider struct {
+	// Audience is embedded in the JWT and must match what the servers
+	// expect to see when validating it.
+	Audience string
+
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func (p *GCPIntroTokenProvider) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return &http.Client{Timeout: defaultIMDSTimeout}
+}
+
+func (p *GCPIntroTokenProvider) baseURL() string {
+	if p.BaseURL != "" {
+		return p.BaseURL
+	}
+	return "http://metadata.google.internal"
+}
+
+func (p *GCPIntroTokenProvider) IntroToken(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/computeMetadata/v1/instance/service-accounts/default/identity?audience=%s&format=full",
+		p.baseURL(), p.Audience)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build GCP metadata request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return "", ErrIntroTokenUnavailable
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden {
+		return "", &IntroTokenDeniedError{Reason: "metadata server denied the identity token request"}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", ErrIntroTokenUnavailable
+	}
+
+	jwt, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read GCP identity token: %w", err)
+	}
+
+	return strings.TrimSpace(string(jwt)), nil
+}
+
+// AzureIMDSIntroTokenProvider obtains the intro token as an MSI access token
+// from Azure Instance Metadata Service.
+type AzureIMDSIntroTokenProvider struct {
+	// Resource is the App ID URI of the resource the token is being
+	// requested for.
+	Resource string
+
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func (p *AzureIMDSIntroTokenProvider) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return &http.Client{Timeout: defaultIMDSTimeout}
+}
+
+func (p *AzureIMDSIntroTokenProvider) baseURL() string {
+	if p.BaseURL != "" {
+		return p.BaseURL
+	}
+	return "http://169.254.169.254"
+}
+
+func (p *AzureIMDSIntroTokenProvider) IntroToken(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/metadata/identity/oauth2/token?api-version=2018-02-01&resource=%s", p.baseURL(), p.Resource)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build Azure MSI request: %w", err)
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return "", ErrIntroTokenUnavailable
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusForbidden {
+		return "", &IntroTokenDeniedError{Reason: "MSI denied the access token request"}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", ErrIntroTokenUnavailable
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := decodeJSONBody(resp, &body); err != nil {
+		return "", fmt.Errorf("failed to decode Azure MSI response: %w", err)
+	}
+
+	if body.AccessToken == "" {
+		return "", ErrIntroTokenUnavailable
+	}
+
+	return body.AccessToken, nil
+}
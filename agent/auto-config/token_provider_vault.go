@@ -0,0 +1,59 @@
+package autoconf
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// VaultIntroTokenProvider retrieves the intro token from a field of the
+// HashiCorp Vault secret read at SecretPath, such as a KV version 1 or 2
+// secret. Only the secret's data is consulted: a token returned in a
+// response's auth block, as auth/token/create issues one, is not read.
+type VaultIntroTokenProvider struct {
+	// Client is the Vault API client to use. It is expected to already be
+	// configured with the address and authentication needed to reach Vault.
+	Client *api.Client
+
+	// SecretPath is the path of the secret to read, e.g.
+	// "secret/data/consul/intro-token".
+	SecretPath string
+
+	// SecretField is the field within the secret's data that holds the
+	// token. Defaults to "token" when empty.
+	SecretField string
+}
+
+func (p *VaultIntroTokenProvider) IntroToken(ctx context.Context) (string, error) {
+	field := p.SecretField
+	if field == "" {
+		field = "token"
+	}
+
+	secret, err := p.Client.Logical().ReadWithContext(ctx, p.SecretPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read intro token from Vault: %w", err)
+	}
+	if secret == nil {
+		return "", ErrIntroTokenUnavailable
+	}
+
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		// KV version 2 nests the actual secret data under a "data" key.
+		data = nested
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", &IntroTokenDeniedError{Reason: fmt.Sprintf("secret %q has no %q field", p.SecretPath, field)}
+	}
+
+	token, ok := value.(string)
+	if !ok || token == "" {
+		return "", &IntroTokenDeniedError{Reason: fmt.Sprintf("secret %q field %q is not a non-empty string", p.SecretPath, field)}
+	}
+
+	return token, nil
+}